@@ -0,0 +1,31 @@
+package simplelog
+
+import (
+	"context"
+)
+
+type loggerContextKey struct{}
+
+// argsの属性を以降の全ての出力にトップレベルのフィールドとして付与したLoggerを返す。
+// args...anyはslog.Attrと同じルールでkey-valueのペアとして解釈される。
+func (l Logger) With(args ...any) Logger {
+	l.Slogger = l.Slogger.With(args...)
+	return l
+}
+
+// loggerをcontext.Contextに紐付ける。
+// リクエスト単位でuser_idやrequest_idなどの属性をWithで付与したLoggerを、
+// 呼び出し元に明示的に引き回すことなく下流の処理に伝播させたい場合に使用する。
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// NewContextで紐付けたLoggerを取り出す。
+// 紐付けられていない場合はデフォルト設定のLoggerを返す。
+func FromContext(ctx context.Context) Logger {
+	l, ok := ctx.Value(loggerContextKey{}).(Logger)
+	if !ok {
+		return New(LOG_LEVEL_INFO, LOG_MODE_SLOGGER, nil, false, false, true, FileConfig{})
+	}
+	return l
+}
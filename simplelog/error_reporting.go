@@ -0,0 +1,51 @@
+package simplelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+func isErrorReportingLevel(level logPrintLevel) bool {
+	switch level {
+	case PRINT_ERROR, PRINT_CRITICAL, PRINT_ALERT, PRINT_EMERGENCY:
+		return true
+	}
+	return false
+}
+
+// Goのpanic時のスタックトレースに似た形式（func\n\tfile:line）で出力する。
+// Cloud Error Reportingはこの形式のスタックトレースをメッセージから自動的に解析する。
+func captureStack(skip int) string {
+	const maxFrames = 32
+	pcs := make([]uintptr, maxFrames)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var sb strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// errのエラーチェーンをerrors.Unwrapで辿り、それぞれのメッセージを連結した上でERRORとして出力する。
+// ただしfmt.Errorf("...%w", wrapped)のように親のError()が既にwrappedのメッセージを
+// 含んでいる場合はそのまま連結すると重複するため、親の文字列に含まれていないメッセージのみ追加する。
+// ReportErrorsが有効な場合はCloud Error Reportingにも連携される。
+func (l Logger) ErrorWithStack(ctx context.Context, err error) {
+	msg := err.Error()
+	for wrapped := errors.Unwrap(err); wrapped != nil; wrapped = errors.Unwrap(wrapped) {
+		wrappedMsg := wrapped.Error()
+		if !strings.Contains(msg, wrappedMsg) {
+			msg += ": " + wrappedMsg
+		}
+	}
+	l.l(ctx, PRINT_ERROR, "%s", 2, msg)
+}
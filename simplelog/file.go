@@ -0,0 +1,41 @@
+package simplelog
+
+import (
+	"log/slog"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// ファイルへのログ出力に関する設定。
+// Cloud Run以外（オンプレ/VM上など）で、ディスクへのログ保存とローテーションを行いたい場合に使用する。
+type FileConfig struct {
+	// 出力先のファイルパス
+	Path string
+	// ローテーションする最大サイズ(MB)
+	MaxSizeMB int
+	// 保持する古いログファイルの世代数
+	MaxBackups int
+	// 保持する日数
+	MaxAgeDays int
+	// 古いログファイルを圧縮するか
+	Compress bool
+	// バックアップファイル名に使うタイムスタンプをローカル時刻にするか（falseの場合はUTC）
+	LocalTime bool
+}
+
+// ファイル出力用のslog.Handlerを作成する。ローテーションを扱うlumberjack.Loggerも
+// 合わせて返すため、呼び出し元はシャットダウン時にこれをCloseすること。
+func NewFileHandler(level slog.Level, config FileConfig) (slog.Handler, *lumberjack.Logger) {
+	lj := &lumberjack.Logger{
+		Filename:   config.Path,
+		MaxSize:    config.MaxSizeMB,
+		MaxBackups: config.MaxBackups,
+		MaxAge:     config.MaxAgeDays,
+		Compress:   config.Compress,
+		LocalTime:  config.LocalTime,
+	}
+	return slog.NewJSONHandler(lj, &slog.HandlerOptions{
+		ReplaceAttr: gcpReplaceAttr,
+		Level:       level,
+	}), lj
+}
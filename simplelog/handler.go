@@ -0,0 +1,79 @@
+package simplelog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// slogをCloud Loggingで必要な形式にカスタマイズする。
+// https://cloud.google.com/logging/docs/structured-logging?hl=ja
+func gcpReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.TimeKey {
+		a.Key = "timestamp"
+	}
+	if a.Key == slog.MessageKey {
+		a.Key = "message"
+	}
+	if a.Key == slog.LevelKey {
+		a.Key = "severity"
+		// Debug/Info/Warn/Errorはslogの標準のString()表記で問題ないが、
+		// Notice/Critical/Alert/Emergencyはslog.Levelの標準レベルに無いため明示的に変換する。
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			if s, ok := customSeverityNames[level]; ok {
+				a.Value = slog.StringValue(s)
+			}
+		}
+	}
+	return a
+}
+
+// MultiHandlerは複数のslog.Handlerへ同一のログをfan-outする。
+// 例えば標準出力(Cloud Logging向け)とLoki向けのHandlerを両方登録すれば、
+// 1つのLoggerで両方の出力先に同時に書き込める。
+type MultiHandler struct {
+	handlers []slog.Handler
+}
+
+func NewMultiHandler(handlers ...slog.Handler) *MultiHandler {
+	return &MultiHandler{handlers: handlers}
+}
+
+func (m *MultiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m.handlers {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MultiHandler) Handle(ctx context.Context, r slog.Record) error {
+	// 1つのHandlerが失敗しても他のHandlerへのfan-outを止めないよう、最後まで回してエラーをまとめる。
+	var errs []error
+	for _, h := range m.handlers {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(m.handlers))
+	for i, h := range m.handlers {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return NewMultiHandler(handlers...)
+}
+
+func (m *MultiHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(m.handlers))
+	for i, h := range m.handlers {
+		handlers[i] = h.WithGroup(name)
+	}
+	return NewMultiHandler(handlers...)
+}
@@ -0,0 +1,55 @@
+package simplelog
+
+import (
+	"context"
+	"log/slog"
+)
+
+// slogの標準レベル(Debug/Info/Warn/Error)には無いGCPのLogSeverityを、
+// 標準レベルの間の値（Notice）およびErrorより上の値（Critical/Alert/Emergency）を使って表現する。
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
+const (
+	LevelNotice    = slog.Level(2)
+	LevelCritical  = slog.Level(12)
+	LevelAlert     = slog.Level(16)
+	LevelEmergency = slog.Level(20)
+)
+
+var customSeverityNames = map[slog.Level]string{
+	LevelNotice:    "NOTICE",
+	LevelCritical:  "CRITICAL",
+	LevelAlert:     "ALERT",
+	LevelEmergency: "EMERGENCY",
+}
+
+func (l Logger) Notice(context context.Context, args ...any) {
+	l.lArgs(context, PRINT_NOTICE, 3, args...)
+}
+
+func (l Logger) Noticef(context context.Context, format string, args ...any) {
+	l.l(context, PRINT_NOTICE, format, 2, args...)
+}
+
+func (l Logger) Critical(context context.Context, args ...any) {
+	l.lArgs(context, PRINT_CRITICAL, 3, args...)
+}
+
+func (l Logger) Criticalf(context context.Context, format string, args ...any) {
+	l.l(context, PRINT_CRITICAL, format, 2, args...)
+}
+
+func (l Logger) Alert(context context.Context, args ...any) {
+	l.lArgs(context, PRINT_ALERT, 3, args...)
+}
+
+func (l Logger) Alertf(context context.Context, format string, args ...any) {
+	l.l(context, PRINT_ALERT, format, 2, args...)
+}
+
+func (l Logger) Emergency(context context.Context, args ...any) {
+	l.lArgs(context, PRINT_EMERGENCY, 3, args...)
+}
+
+func (l Logger) Emergencyf(context context.Context, format string, args ...any) {
+	l.l(context, PRINT_EMERGENCY, format, 2, args...)
+}
@@ -3,9 +3,10 @@ package simplelog
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
-	"os"
 	"runtime"
 )
 
@@ -15,6 +16,14 @@ type Logger struct {
 	LogMode       LogMode
 	LogHandler    LogHandler
 	UseGcpLogging bool
+
+	// trueの場合、Error/Critical/Alert/EmergencyでCloud Error Reportingが取り込める形式の
+	// 情報（@typeとスタックトレース）を付与する。UseGcpLoggingがtrueの場合のみ有効。
+	ReportErrors bool
+
+	// io.Closerを実装するSink（ファイル出力のlumberjack.LoggerやLokiHandlerなど）。
+	// Close()でまとめてCloseするために保持する。
+	closers []io.Closer
 }
 
 // メッセージ、ラベル、トレースを設定するためのインターフェース
@@ -26,11 +35,42 @@ type LogHandler interface {
 
 type LogLevel int
 
+// LOG_LEVEL_INFO/LOG_LEVEL_DEBUGの値は元の定義（0, 1）から変更していない。
+// 以降のGCPのLogSeverityに対応するレベルはseverityの昇順ではなく末尾に追加しているため、
+// この並び順はしきい値の判定には使用しない（しきい値の判定はslogLevel()で個別に行う）。
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
 const (
 	LOG_LEVEL_INFO LogLevel = iota
 	LOG_LEVEL_DEBUG
+	LOG_LEVEL_NOTICE
+	LOG_LEVEL_WARN
+	LOG_LEVEL_ERROR
+	LOG_LEVEL_CRITICAL
+	LOG_LEVEL_ALERT
+	LOG_LEVEL_EMERGENCY
 )
 
+func (ll LogLevel) slogLevel() slog.Level {
+	switch ll {
+	case LOG_LEVEL_DEBUG:
+		return slog.LevelDebug
+	case LOG_LEVEL_NOTICE:
+		return LevelNotice
+	case LOG_LEVEL_WARN:
+		return slog.LevelWarn
+	case LOG_LEVEL_ERROR:
+		return slog.LevelError
+	case LOG_LEVEL_CRITICAL:
+		return LevelCritical
+	case LOG_LEVEL_ALERT:
+		return LevelAlert
+	case LOG_LEVEL_EMERGENCY:
+		return LevelEmergency
+	default:
+		return slog.LevelInfo
+	}
+}
+
 type LogMode int
 
 const (
@@ -42,46 +82,78 @@ const (
 type logPrintLevel string
 
 const (
-	PRINT_DEBG  logPrintLevel = "DEBG"
-	PRINT_INFO  logPrintLevel = "INFO"
-	PRINT_WARN  logPrintLevel = "WARN"
-	PRINT_ERROR logPrintLevel = "ERROR"
+	PRINT_DEBG      logPrintLevel = "DEBG"
+	PRINT_INFO      logPrintLevel = "INFO"
+	PRINT_NOTICE    logPrintLevel = "NOTICE"
+	PRINT_WARN      logPrintLevel = "WARN"
+	PRINT_ERROR     logPrintLevel = "ERROR"
+	PRINT_CRITICAL  logPrintLevel = "CRITICAL"
+	PRINT_ALERT     logPrintLevel = "ALERT"
+	PRINT_EMERGENCY logPrintLevel = "EMERGENCY"
 )
 
-func New(logLevel LogLevel, logMode LogMode, logHandler LogHandler, useGcpLogging bool) Logger {
+// logSaveFile / logInConsoleはそれぞれ独立に指定可能。
+// 両方trueであればファイルとコンソールの両方に出力され、両方falseの場合はコンソールのみに出力する。
+// logSaveFileがtrueの場合、fileConfigでローテーションの設定を行う。
+func New(logLevel LogLevel, logMode LogMode, logHandler LogHandler, useGcpLogging bool, logSaveFile bool, logInConsole bool, fileConfig FileConfig) Logger {
 	l := Logger{}
-	l.LogLevel = slog.LevelInfo
-	if logLevel >= LOG_LEVEL_DEBUG {
-		l.LogLevel = slog.LevelDebug
+	l.LogLevel = logLevel.slogLevel()
+
+	handlers := []slog.Handler{}
+	if logSaveFile {
+		fileHandler, lj := NewFileHandler(l.LogLevel, fileConfig)
+		handlers = append(handlers, fileHandler)
+		// NewFileHandlerが返すslog.HandlerはCloseを持たないため、lumberjack.Logger自体を保持する。
+		l.closers = append(l.closers, lj)
+	}
+	if logInConsole || len(handlers) == 0 {
+		handlers = append(handlers, NewStdoutHandler(l.LogLevel))
 	}
+	return newLogger(l, logMode, logHandler, useGcpLogging, handlers...)
+}
+
+// 出力先をSink（slog.Handler）として自由に組み合わせたい場合に使用するコンストラクタ。
+// 標準出力/標準エラー/ファイル/Lokiなど、複数のHandlerを渡すとMultiHandlerでfan-outされる。
+func NewWithHandlers(logLevel LogLevel, logMode LogMode, logHandler LogHandler, useGcpLogging bool, handlers ...slog.Handler) Logger {
+	l := Logger{}
+	l.LogLevel = logLevel.slogLevel()
+	return newLogger(l, logMode, logHandler, useGcpLogging, handlers...)
+}
+
+func newLogger(l Logger, logMode LogMode, logHandler LogHandler, useGcpLogging bool, handlers ...slog.Handler) Logger {
 	l.LogMode = logMode
 	l.LogHandler = logHandler
 	l.UseGcpLogging = useGcpLogging
 
-	// slogをCloud Loggingで必要な形式にカスタマイズする。
-	// https://cloud.google.com/logging/docs/structured-logging?hl=ja
-	replacer := func(groups []string, a slog.Attr) slog.Attr {
-		if a.Key == slog.TimeKey {
-			a.Key = "timestamp"
-		}
-		if a.Key == slog.MessageKey {
-			a.Key = "message"
-		}
-		if a.Key == slog.LevelKey {
-			a.Key = "severity"
+	// LokiHandlerのようにio.Closerを実装しているHandlerは、Close()でまとめてCloseできるよう保持する。
+	for _, h := range handlers {
+		if c, ok := h.(io.Closer); ok {
+			l.closers = append(l.closers, c)
 		}
-		return a
 	}
-	l.Slogger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		ReplaceAttr: replacer,
-		Level:       l.LogLevel,
-		// AddSource: true,
-		// AddSource は使わずにruntime.Callerを使っている。
-		// AddSourceは呼び出し元ではなく本ファイルおよびその行数が出力されてしまうため。
-	}))
+
+	var handler slog.Handler
+	if len(handlers) == 1 {
+		handler = handlers[0]
+	} else {
+		handler = NewMultiHandler(handlers...)
+	}
+	l.Slogger = slog.New(handler)
 	return l
 }
 
+// LogSaveFileでのファイル出力やLokiへのバッチ送信など、io.Closerを実装するSinkをまとめてCloseする。
+// シャットダウン時に呼び出し、バッファの取りこぼしを防ぐこと。
+func (l Logger) Close() error {
+	var errs []error
+	for _, c := range l.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (l Logger) l(context context.Context, level logPrintLevel, format string, skip int, args ...any) {
 	// ファイル名と行数の情報を設定
 	_, file, line, ok := runtime.Caller(skip)
@@ -91,10 +163,16 @@ func (l Logger) l(context context.Context, level logPrintLevel, format string, s
 		callerAttr = slog.Group("logging.googleapis.com/sourceLocation", slog.String("file", file), slog.Int("line", line))
 	}
 
-	// 「logging.googleapis.com/trace」はCloud Traceへの連携
+	// 「logging.googleapis.com/trace」「spanId」「trace_sampled」はCloud Traceへの連携
 	// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#FIELDS.trace
-	var traceAttr slog.Attr
-	if l.LogHandler != nil {
+	var traceAttr, spanAttr, sampledAttr slog.Attr
+	if info, ok := traceFromContext(context); ok {
+		traceAttr = slog.String("logging.googleapis.com/trace", info.trace)
+		if info.spanID != "" {
+			spanAttr = slog.String("logging.googleapis.com/spanId", info.spanID)
+		}
+		sampledAttr = slog.Bool("logging.googleapis.com/trace_sampled", info.sampled)
+	} else if l.LogHandler != nil {
 		traceAttr = slog.String("logging.googleapis.com/trace", l.LogHandler.GetTrace(l, context))
 	}
 
@@ -114,6 +192,16 @@ func (l Logger) l(context context.Context, level logPrintLevel, format string, s
 		out = l.LogHandler.GetMessage(l, context, string(level), file, line, out)
 	}
 
+	// Cloud Error Reportingへの連携
+	// https://cloud.google.com/error-reporting/docs/formatting-error-messages
+	var errorReportingAttr slog.Attr
+	if l.ReportErrors && l.UseGcpLogging && isErrorReportingLevel(level) {
+		errorReportingAttr = slog.String("@type", "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent")
+		// runtime.Callersはruntime.Callerとskipの基準が異なり、かつcaptureStack自体の
+		// フレームが1つ挟まるため、呼び出し元から始まるようskipを+2して渡す。
+		out = out + "\n" + captureStack(skip+2)
+	}
+
 	if l.LogMode == LOG_MODE_FMT {
 		fmt.Println(out)
 		return
@@ -122,28 +210,52 @@ func (l Logger) l(context context.Context, level logPrintLevel, format string, s
 	switch level {
 	case PRINT_DEBG:
 		if l.UseGcpLogging {
-			l.Slogger.Debug(out, callerAttr, traceAttr, labelsAttr)
+			l.Slogger.Debug(out, callerAttr, traceAttr, spanAttr, sampledAttr, labelsAttr)
 		} else {
 			l.Slogger.Debug(out)
 		}
 	case PRINT_INFO:
 		if l.UseGcpLogging {
-			l.Slogger.Info(out, callerAttr, traceAttr, labelsAttr)
+			l.Slogger.Info(out, callerAttr, traceAttr, spanAttr, sampledAttr, labelsAttr)
 		} else {
 			l.Slogger.Info(out)
 		}
 	case PRINT_WARN:
 		if l.UseGcpLogging {
-			l.Slogger.Warn(out, callerAttr, traceAttr, labelsAttr)
+			l.Slogger.Warn(out, callerAttr, traceAttr, spanAttr, sampledAttr, labelsAttr)
 		} else {
 			l.Slogger.Warn(out)
 		}
 	case PRINT_ERROR:
 		if l.UseGcpLogging {
-			l.Slogger.Error(out, slog.String("error", out), callerAttr, traceAttr, labelsAttr)
+			l.Slogger.Error(out, slog.String("error", out), callerAttr, traceAttr, spanAttr, sampledAttr, labelsAttr, errorReportingAttr)
 		} else {
 			l.Slogger.Error(out)
 		}
+	case PRINT_NOTICE:
+		if l.UseGcpLogging {
+			l.Slogger.Log(context, LevelNotice, out, callerAttr, traceAttr, spanAttr, sampledAttr, labelsAttr)
+		} else {
+			l.Slogger.Log(context, LevelNotice, out)
+		}
+	case PRINT_CRITICAL:
+		if l.UseGcpLogging {
+			l.Slogger.Log(context, LevelCritical, out, slog.String("error", out), callerAttr, traceAttr, spanAttr, sampledAttr, labelsAttr, errorReportingAttr)
+		} else {
+			l.Slogger.Log(context, LevelCritical, out)
+		}
+	case PRINT_ALERT:
+		if l.UseGcpLogging {
+			l.Slogger.Log(context, LevelAlert, out, slog.String("error", out), callerAttr, traceAttr, spanAttr, sampledAttr, labelsAttr, errorReportingAttr)
+		} else {
+			l.Slogger.Log(context, LevelAlert, out)
+		}
+	case PRINT_EMERGENCY:
+		if l.UseGcpLogging {
+			l.Slogger.Log(context, LevelEmergency, out, slog.String("error", out), callerAttr, traceAttr, spanAttr, sampledAttr, labelsAttr, errorReportingAttr)
+		} else {
+			l.Slogger.Log(context, LevelEmergency, out)
+		}
 	}
 }
 
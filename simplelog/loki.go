@@ -0,0 +1,229 @@
+package simplelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Loki(https://grafana.com/oss/loki/)へログをpushするための設定。
+type LokiConfig struct {
+	// LokiのホストおよびPort
+	Host string
+	Port int
+	// ストリームに付与するjobラベル
+	Job string
+	// ストリームに付与するsourceラベル
+	Source string
+	// 上記以外に付与したい追加のラベル
+	Labels map[string]string
+	// バッファに溜まったエントリをflushするサイズ。0以下の場合はdefaultLokiBatchSizeを使用する。
+	BatchSize int
+	// バッファを定期的にflushする間隔。0以下の場合はdefaultLokiFlushIntervalを使用する。
+	FlushInterval time.Duration
+}
+
+const (
+	defaultLokiBatchSize     = 100
+	defaultLokiFlushInterval = 2 * time.Second
+)
+
+// lokiCoreはWithAttrs/WithGroupで派生したLokiHandler間で共有するバッファと
+// バックグラウンドでのflush処理を持つ。
+type lokiCore struct {
+	config LokiConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	values [][2]string
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Lokiの `/loki/api/v1/push` へログをバッチでPOSTするHandler。
+// オンプレ/VM上でGrafana + Lokiのスタックへログを流し込みたい場合に使用する。
+// Handle自体はエントリをバッファに積むだけで即座に返り、実際のPOSTは
+// バックグラウンドのgoroutineがサイズ・間隔のいずれかの条件でflushする。
+// プロセス終了時にバッファを取りこぼさないよう、必ずClose()を呼ぶこと。
+type LokiHandler struct {
+	core        *lokiCore
+	level       slog.Level
+	attrs       []slog.Attr
+	groupPrefix string
+}
+
+func NewLokiHandler(level slog.Level, config LokiConfig) *LokiHandler {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultLokiBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultLokiFlushInterval
+	}
+
+	core := &lokiCore{
+		config: config,
+		client: &http.Client{Timeout: 5 * time.Second},
+		stopCh: make(chan struct{}),
+	}
+	core.wg.Add(1)
+	go core.run()
+
+	return &LokiHandler{core: core, level: level}
+}
+
+func (core *lokiCore) run() {
+	defer core.wg.Done()
+	ticker := time.NewTicker(core.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			core.flush()
+		case <-core.stopCh:
+			core.flush()
+			return
+		}
+	}
+}
+
+func (core *lokiCore) enqueue(ts string, line string) {
+	core.mu.Lock()
+	core.values = append(core.values, [2]string{ts, line})
+	shouldFlush := len(core.values) >= core.config.BatchSize
+	core.mu.Unlock()
+
+	// バッチサイズに達した場合は次の定期flushを待たずに非同期でflushする。
+	// Handleの呼び出し元をLokiへのネットワークI/Oで待たせないため。
+	// このflushもwgで数え、Closeが完了を待てるようにする。
+	if shouldFlush {
+		core.wg.Add(1)
+		go func() {
+			defer core.wg.Done()
+			core.flush()
+		}()
+	}
+}
+
+func (core *lokiCore) flush() {
+	core.mu.Lock()
+	if len(core.values) == 0 {
+		core.mu.Unlock()
+		return
+	}
+	values := core.values
+	core.values = nil
+	core.mu.Unlock()
+
+	labels := map[string]string{
+		"job":    core.config.Job,
+		"source": core.config.Source,
+	}
+	for k, v := range core.config.Labels {
+		labels[k] = v
+	}
+	payload := map[string]any{
+		"streams": []map[string]any{
+			{
+				"stream": labels,
+				"values": values,
+			},
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	url := fmt.Sprintf("http://%s:%d/loki/api/v1/push", core.config.Host, core.config.Port)
+	resp, err := core.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+}
+
+// Close はバックグラウンドのflushループを止め、バッファに残ったエントリをflushしてから返る。
+func (h *LokiHandler) Close() error {
+	h.core.stopOnce.Do(func() {
+		close(h.core.stopCh)
+	})
+	h.core.wg.Wait()
+	return nil
+}
+
+func (h *LokiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *LokiHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := map[string]any{
+		"timestamp": r.Time.Format(time.RFC3339Nano),
+		"severity":  severityString(r.Level),
+		"message":   r.Message,
+	}
+	for _, a := range h.attrs {
+		flattenAttr(fields, h.groupPrefix, a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		flattenAttr(fields, h.groupPrefix, a)
+		return true
+	})
+
+	line, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	h.core.enqueue(strconv.FormatInt(r.Time.UnixNano(), 10), string(line))
+	return nil
+}
+
+func (h *LokiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LokiHandler{
+		core:        h.core,
+		level:       h.level,
+		attrs:       append(append([]slog.Attr{}, h.attrs...), attrs...),
+		groupPrefix: h.groupPrefix,
+	}
+}
+
+func (h *LokiHandler) WithGroup(name string) slog.Handler {
+	groupPrefix := name
+	if h.groupPrefix != "" {
+		groupPrefix = h.groupPrefix + "." + name
+	}
+	return &LokiHandler{
+		core:        h.core,
+		level:       h.level,
+		attrs:       h.attrs,
+		groupPrefix: groupPrefix,
+	}
+}
+
+func severityString(level slog.Level) string {
+	if s, ok := customSeverityNames[level]; ok {
+		return s
+	}
+	return level.String()
+}
+
+func flattenAttr(fields map[string]any, prefix string, a slog.Attr) {
+	key := a.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, sub := range a.Value.Group() {
+			flattenAttr(fields, key, sub)
+		}
+		return
+	}
+	fields[key] = a.Value.Any()
+}
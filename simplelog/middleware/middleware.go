@@ -0,0 +1,126 @@
+// Package middleware は、HTTPリクエスト単位でGCPの`httpRequest`ログエントリを
+// 1件出力するnet/httpミドルウェアを提供する。Cloud Run上で動かすアプリケーションの
+// アクセスログ用途を想定している。
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/megur0/simple-logger/simplelog"
+)
+
+// レスポンスのstatus/byte数を記録するためのラッパー。
+// 元のResponseWriterがhttp.Flusher/http.Hijackerを実装している場合はそれを引き継ぐ
+// （実装していない場合にそれらを呼ぶとストリーミング配信やWebSocketのアップグレードが壊れるため）。
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusRecorder) WriteHeader(statusCode int) {
+	w.status = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("simplelog/middleware: underlying ResponseWriter is not an http.Hijacker")
+	}
+	return h.Hijack()
+}
+
+// Middlewareはsimplelog.Loggerを保持し、HTTPHandlerを通してリクエストログを出力する。
+// simplelog.LoggerはGoのメソッドルール上simplelog外でメソッドを定義できないため、
+// `func (Logger) HTTPHandler(...)`の代わりにこのラッパー型にメソッドとして生やしている。
+type Middleware struct {
+	Logger    simplelog.Logger
+	ProjectID string
+}
+
+// New はMiddlewareを作成する。
+// projectIDは`traceparent`/`X-Cloud-Trace-Context`からトレースを抽出する際に
+// `logging.googleapis.com/trace`の値を組み立てるために使用する。
+func New(logger simplelog.Logger, projectID string) *Middleware {
+	return &Middleware{Logger: logger, ProjectID: projectID}
+}
+
+// HTTPHandlerはnextをラップし、リクエスト完了時にGCPの`httpRequest`フィールドを
+// 持つログエントリを1件出力する。
+// 抽出したトレースはリクエストのcontext.Contextに積み込まれるため、
+// next以下で`logger.Info(r.Context(), ...)`を呼べば同じトレースでCloud Traceと相関する。
+func (m *Middleware) HTTPHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		traceField, spanField, sampled := simplelog.ExtractTraceFromRequest(r, m.ProjectID)
+		ctx := r.Context()
+		if traceField != "" {
+			ctx = simplelog.ContextWithTrace(ctx, traceField, spanField, sampled)
+		}
+		r = r.WithContext(ctx)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		latency := time.Since(start)
+
+		// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+		attrs := []any{
+			slog.Group("httpRequest",
+				slog.String("requestMethod", r.Method),
+				slog.String("requestUrl", r.URL.String()),
+				slog.Int("status", status),
+				slog.Int("responseSize", rec.bytes),
+				slog.String("userAgent", r.UserAgent()),
+				slog.String("remoteIp", remoteIP(r.RemoteAddr)),
+				slog.String("referer", r.Referer()),
+				slog.String("latency", fmt.Sprintf("%.3fs", latency.Seconds())),
+			),
+		}
+		if traceField != "" {
+			attrs = append(attrs, slog.String("logging.googleapis.com/trace", traceField))
+			if spanField != "" {
+				attrs = append(attrs, slog.String("logging.googleapis.com/spanId", spanField))
+			}
+			attrs = append(attrs, slog.Bool("logging.googleapis.com/trace_sampled", sampled))
+		}
+
+		m.Logger.Slogger.Info(fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, status), attrs...)
+	})
+}
+
+// GCPのhttpRequest.remoteIpはポート番号を含まない裸のIPを期待するため、
+// net/httpのRemoteAddr（"host:port"形式）からポートを取り除く。
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -0,0 +1,25 @@
+package simplelog
+
+import (
+	"log/slog"
+	"os"
+)
+
+// 標準出力へJSON形式で出力するHandler。Cloud Run上ではこれが標準の出力先となる。
+func NewStdoutHandler(level slog.Level) slog.Handler {
+	return slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		ReplaceAttr: gcpReplaceAttr,
+		Level:       level,
+		// AddSource: true,
+		// AddSource は使わずにruntime.Callerを使っている。
+		// AddSourceは呼び出し元ではなく本ファイルおよびその行数が出力されてしまうため。
+	})
+}
+
+// 標準エラー出力へJSON形式で出力するHandler。
+func NewStderrHandler(level slog.Level) slog.Handler {
+	return slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		ReplaceAttr: gcpReplaceAttr,
+		Level:       level,
+	})
+}
@@ -0,0 +1,95 @@
+package simplelog
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type traceContextKey struct{}
+
+type traceInfo struct {
+	trace   string
+	spanID  string
+	sampled bool
+}
+
+// ExtractTraceFromRequestで抽出したトレース情報をcontext.Contextに紐付ける。
+// 以降、このcontextを使ったLogger呼び出しではLogHandler.GetTraceより優先してこの情報が使われる。
+func ContextWithTrace(ctx context.Context, trace string, spanID string, sampled bool) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, traceInfo{trace: trace, spanID: spanID, sampled: sampled})
+}
+
+func traceFromContext(ctx context.Context) (traceInfo, bool) {
+	info, ok := ctx.Value(traceContextKey{}).(traceInfo)
+	return info, ok
+}
+
+// HTTPリクエストのヘッダからトレース情報を抽出し、Cloud Loggingの
+// `logging.googleapis.com/trace`、`logging.googleapis.com/spanId`、`logging.googleapis.com/trace_sampled`に
+// 設定できる形式へ変換する。
+//
+// 以下の2つの形式に対応する。
+//   - レガシー形式: X-Cloud-Trace-Context: TRACE_ID/SPAN_ID;o=TRACE_TRUE
+//   - W3C形式: traceparent: 00-TRACE_ID-SPAN_ID-FLAGS
+//
+// いずれのヘッダも存在しない場合は、LogHandler.GetTraceへフォールバックするため
+// traceField、spanFieldともに空文字列を返す。
+func ExtractTraceFromRequest(r *http.Request, projectID string) (traceField string, spanField string, sampled bool) {
+	if traceID, spanID, s, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		return fmt.Sprintf("projects/%s/traces/%s", projectID, traceID), spanID, s
+	}
+	if traceID, spanID, s, ok := parseCloudTraceContext(r.Header.Get("X-Cloud-Trace-Context")); ok {
+		return fmt.Sprintf("projects/%s/traces/%s", projectID, traceID), spanID, s
+	}
+	return "", "", false
+}
+
+// X-Cloud-Trace-Context: TRACE_ID/SPAN_ID;o=TRACE_TRUE
+func parseCloudTraceContext(header string) (traceID string, spanID string, sampled bool, ok bool) {
+	if header == "" {
+		return "", "", false, false
+	}
+	parts := strings.SplitN(header, "/", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", false, false
+	}
+	traceID = parts[0]
+	rest := parts[1]
+	spanDecimal := rest
+	if i := strings.IndexByte(rest, ';'); i >= 0 {
+		spanDecimal = rest[:i]
+		sampled = strings.Contains(rest[i:], "o=1")
+	}
+	return traceID, spanDecimal, sampled, true
+}
+
+// traceparent: 00-TRACE_ID(32hex)-SPAN_ID(16hex)-FLAGS(2hex)
+func parseTraceparent(header string) (traceID string, spanID string, sampled bool, ok bool) {
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 {
+		return "", "", false, false
+	}
+	traceID, spanHex, flagsHex := parts[1], parts[2], parts[3]
+	if len(traceID) != 32 || len(spanHex) != 16 || len(flagsHex) != 2 {
+		return "", "", false, false
+	}
+	spanBytes, err := hex.DecodeString(spanHex)
+	if err != nil {
+		return "", "", false, false
+	}
+	// Cloud LoggingのspanIdは64bitの10進数表記を期待する。
+	spanDecimal := strconv.FormatUint(binary.BigEndian.Uint64(spanBytes), 10)
+
+	flags, err := hex.DecodeString(flagsHex)
+	if err != nil {
+		return "", "", false, false
+	}
+	sampled = len(flags) == 1 && flags[0]&0x01 == 1
+
+	return traceID, spanDecimal, sampled, true
+}